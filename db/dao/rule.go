@@ -0,0 +1,39 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2014-2017 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package dao
+
+import "github.com/goodrain/rainbond/db/model"
+
+//HttpRuleDao http rule data access object. GetHttpRulesByServiceIDAndContainerPort
+//replaces the old GetHttpRuleByServiceIDAndContainerPort now that a single
+//service port can be exposed by more than one HTTPRule.
+type HttpRuleDao interface {
+	GetHttpRulesByServiceIDAndContainerPort(serviceID string, containerPort int) ([]*model.HTTPRule, error)
+}
+
+//TcpRuleDao tcp rule data access object. GetTcpRulesByServiceIDAndContainerPort
+//replaces the old GetTcpRuleByServiceIDAndContainerPort for the same reason.
+type TcpRuleDao interface {
+	GetTcpRulesByServiceIDAndContainerPort(serviceID string, containerPort int) ([]*model.TCPRule, error)
+}
+
+//AuthSecretDao resolves the AuthSecret a basic-auth rule extension references
+type AuthSecretDao interface {
+	GetAuthSecretByID(uuid string) (*model.AuthSecret, error)
+}
@@ -0,0 +1,33 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2014-2017 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package model
+
+//AuthSecret is the Kubernetes Secret backing a basic-auth rule extension.
+//UUID is what an HTTPRule's AuthSecret extension value references;
+//SecretName is the name of the k8s Secret the gateway actually reads.
+type AuthSecret struct {
+	UUID       string `gorm:"column:uuid;size:32" json:"uuid"`
+	TenantID   string `gorm:"column:tenant_id;size:32" json:"tenant_id"`
+	SecretName string `gorm:"column:secret_name" json:"secret_name"`
+}
+
+//TableName return table name of AuthSecret
+func (t *AuthSecret) TableName() string {
+	return "gw_auth_secret"
+}
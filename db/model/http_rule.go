@@ -0,0 +1,41 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2014-2017 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package model
+
+//PathMatchType is how a gateway matches HTTPRule.Path and whether/how it
+//rewrites the path before forwarding to the backend
+type PathMatchType string
+
+//path match types supported by HTTPRule.PathMatchType
+const (
+	//PathPrefix is the historical default: match Path as a prefix, forward unchanged
+	PathPrefix PathMatchType = "PathPrefix"
+	//ExactPath matches Path exactly
+	ExactPath PathMatchType = "ExactPath"
+	//PathStrip matches Path as a prefix and forwards "/" in its place
+	PathStrip PathMatchType = "PathStrip"
+	//PathPrefixStrip matches Path as a prefix and strips it from the forwarded request
+	PathPrefixStrip PathMatchType = "PathPrefixStrip"
+	//AddPrefix prepends the PathRewriteTarget extension value to the forwarded request
+	AddPrefix PathMatchType = "AddPrefix"
+	//ReplacePath replaces the forwarded path with the PathRewriteTarget extension value
+	ReplacePath PathMatchType = "ReplacePath"
+	//ReplacePathRegex replaces the forwarded path using the PathRewriteTarget extension value as a regex replacement
+	ReplacePathRegex PathMatchType = "ReplacePathRegex"
+)
@@ -0,0 +1,34 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2014-2017 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package model
+
+//HTTPRuleBackend is one weighted backend of an HTTPRule, letting a single
+//domain/path split traffic across several Rainbond services for blue/green
+//and canary rollouts
+type HTTPRuleBackend struct {
+	RuleID        string `gorm:"column:rule_id;size:32" json:"rule_id"`
+	ServiceID     string `gorm:"column:service_id;size:32" json:"service_id"`
+	ContainerPort int    `gorm:"column:container_port" json:"container_port"`
+	Weight        int    `gorm:"column:weight" json:"weight"`
+}
+
+//TableName return table name of HTTPRuleBackend
+func (t *HTTPRuleBackend) TableName() string {
+	return "gw_http_rule_backend"
+}
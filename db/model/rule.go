@@ -0,0 +1,51 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2014-2017 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package model
+
+//HTTPRule is a http gateway rule, mapping a domain/path/cert to a service port
+type HTTPRule struct {
+	UUID          string        `gorm:"column:uuid;size:32" json:"uuid"`
+	ServiceID     string        `gorm:"column:service_id;size:32" json:"service_id"`
+	ContainerPort int           `gorm:"column:container_port" json:"container_port"`
+	Domain        string        `gorm:"column:domain" json:"domain"`
+	Path          string        `gorm:"column:path" json:"path"`
+	Header        string        `gorm:"column:header" json:"header"`
+	Cookie        string        `gorm:"column:cookie" json:"cookie"`
+	CertificateID string        `gorm:"column:certificate_id;size:32" json:"certificate_id"`
+	PathMatchType PathMatchType `gorm:"column:path_match_type" json:"path_match_type"`
+}
+
+//TableName return table name of HTTPRule
+func (t *HTTPRule) TableName() string {
+	return "gw_http_rule"
+}
+
+//TCPRule is a stream gateway rule, mapping an IP/port to a service port
+type TCPRule struct {
+	UUID          string `gorm:"column:uuid;size:32" json:"uuid"`
+	ServiceID     string `gorm:"column:service_id;size:32" json:"service_id"`
+	ContainerPort int    `gorm:"column:container_port" json:"container_port"`
+	IP            string `gorm:"column:ip" json:"ip"`
+	Port          int    `gorm:"column:port" json:"port"`
+}
+
+//TableName return table name of TCPRule
+func (t *TCPRule) TableName() string {
+	return "gw_tcp_rule"
+}
@@ -0,0 +1,51 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2014-2017 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package model
+
+//RuleExtensionKey rule extension key
+type RuleExtensionKey string
+
+//PathRewriteTarget carries the rewrite destination for the AddPrefix,
+//ReplacePath and ReplacePathRegex path match types
+const PathRewriteTarget RuleExtensionKey = "PathRewriteTarget"
+
+//extension keys backing basic-auth and IP whitelist rule policies
+const (
+	//AuthType is the basic-auth auth-type, e.g. "basic"
+	AuthType RuleExtensionKey = "AuthType"
+	//AuthSecret is the UUID of the AuthSecret holding the htpasswd-style secret
+	AuthSecret RuleExtensionKey = "AuthSecret"
+	//AuthRealm is the realm shown in the basic-auth prompt
+	AuthRealm RuleExtensionKey = "AuthRealm"
+	//WhitelistSourceRange is a comma-separated list of CIDRs allowed to reach the rule
+	WhitelistSourceRange RuleExtensionKey = "WhitelistSourceRange"
+)
+
+//extension keys letting a rule coexist with other gateway deployments and
+//customize backend protocol / request-response headers
+const (
+	//IngressClass overrides the default EX_INGRESS_CLASS for this rule's ingress
+	IngressClass RuleExtensionKey = "IngressClass"
+	//BackendProtocol is the protocol used to talk to the backend: http, https or h2c
+	BackendProtocol RuleExtensionKey = "BackendProtocol"
+	//CustomRequestHeaders is a list of headers to add to the proxied request
+	CustomRequestHeaders RuleExtensionKey = "CustomRequestHeaders"
+	//CustomResponseHeaders is a list of headers to add to the proxied response
+	CustomResponseHeaders RuleExtensionKey = "CustomResponseHeaders"
+)
@@ -19,9 +19,12 @@
 package conversion
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/goodrain/rainbond/db"
@@ -65,6 +68,7 @@ func TenantServiceRegist(as *v1.AppService, dbmanager db.Manager) error {
 		logrus.Error("build k8s services error.", err.Error())
 		return err
 	}
+	// svcs also carries the inner services of any HTTPRuleBackend, not just as.ServiceID
 	for _, service := range svcs {
 		as.SetService(service)
 	}
@@ -87,6 +91,10 @@ type AppServiceBuild struct {
 	logger             event.Logger
 	replicationType    string
 	appService         *v1.AppService
+	//ingressClass is the default kubernetes.io/ingress.class, overridable per rule
+	ingressClass string
+	//certSecrets caches the TLS secret built for a CertificateID
+	certSecrets map[string]*corev1.Secret
 }
 
 //AppServiceBuilder returns a AppServiceBuild
@@ -114,6 +122,7 @@ func AppServiceBuilder(serviceID, replicationType string, dbmanager db.Manager,
 		tenant:          tenant,
 		replicationType: replicationType,
 		appService:      as,
+		ingressClass:    os.Getenv("EX_INGRESS_CLASS"),
 	}, nil
 }
 
@@ -153,14 +162,13 @@ func (a *AppServiceBuild) Build() ([]*corev1.Service, []*extensions.Ingress, []*
 			if port.IsOuterService {
 				service := a.createOuterService(port)
 
-				ings, secret, err := a.ApplyRules(port, service)
+				ings, secs, backends, err := a.ApplyRules(port, service)
 				if err != nil {
 					return nil, nil, nil, err
 				}
 				ingresses = append(ingresses, ings...)
-				if secret != nil {
-					secrets = append(secrets, secret)
-				}
+				secrets = append(secrets, secs...)
+				services = append(services, backends...)
 
 				services = append(services, service)
 			}
@@ -175,54 +183,88 @@ func (a *AppServiceBuild) Build() ([]*corev1.Service, []*extensions.Ingress, []*
 		services, _ = a.CreateUpstreamPluginMappingService(services, pp)
 	}
 
+	aggregateIngressTLSHosts(ingresses)
+
 	return services, ingresses, secrets, nil
 }
 
-// ApplyRules applies http rules and tcp rules
-func (a AppServiceBuild) ApplyRules(port *model.TenantServicesPort,
-	service *corev1.Service) ([]*extensions.Ingress, *corev1.Secret, error) {
-	httpRule, err := a.dbmanager.HttpRuleDao().GetHttpRuleByServiceIDAndContainerPort(port.ServiceID,
-		port.ContainerPort) // TODO: http rule should be more than one
+// aggregateIngressTLSHosts makes every ingress sharing a TLS secret list the
+// full set of hosts covered by that secret, for correct SNI matching
+func aggregateIngressTLSHosts(ingresses []*extensions.Ingress) {
+	seenBySecret := make(map[string]map[string]bool)
+	var hostsBySecret = make(map[string][]string)
+	for _, ing := range ingresses {
+		for _, tls := range ing.Spec.TLS {
+			if seenBySecret[tls.SecretName] == nil {
+				seenBySecret[tls.SecretName] = make(map[string]bool)
+			}
+			for _, host := range tls.Hosts {
+				if seenBySecret[tls.SecretName][host] {
+					continue
+				}
+				seenBySecret[tls.SecretName][host] = true
+				hostsBySecret[tls.SecretName] = append(hostsBySecret[tls.SecretName], host)
+			}
+		}
+	}
+	for _, ing := range ingresses {
+		for i := range ing.Spec.TLS {
+			ing.Spec.TLS[i].Hosts = hostsBySecret[ing.Spec.TLS[i].SecretName]
+		}
+	}
+}
+
+// ApplyRules applies http rules and tcp rules. A single service port may be
+// exposed by more than one HTTPRule/TCPRule (different domains, paths, or
+// protocols), so every matching rule is converted into its own ingress.
+func (a *AppServiceBuild) ApplyRules(port *model.TenantServicesPort,
+	service *corev1.Service) ([]*extensions.Ingress, []*corev1.Secret, []*corev1.Service, error) {
+	httpRules, err := a.dbmanager.HttpRuleDao().GetHttpRulesByServiceIDAndContainerPort(port.ServiceID,
+		port.ContainerPort)
 	if err != nil {
-		logrus.Infof("Can't get HTTPRule corresponding to ServiceID(%s): %v", port.ServiceID, err)
+		logrus.Infof("Can't get HTTPRules corresponding to ServiceID(%s): %v", port.ServiceID, err)
 	}
-	tcpRule, err := a.dbmanager.TcpRuleDao().GetTcpRuleByServiceIDAndContainerPort(port.ServiceID,
-		port.ContainerPort) // TODO: tcp rule should be more than one
+	tcpRules, err := a.dbmanager.TcpRuleDao().GetTcpRulesByServiceIDAndContainerPort(port.ServiceID,
+		port.ContainerPort)
 	if err != nil {
-		logrus.Infof("Can't get TCPRule corresponding to ServiceID(%s): %v", port.ServiceID, err)
+		logrus.Infof("Can't get TCPRules corresponding to ServiceID(%s): %v", port.ServiceID, err)
 	}
-	if httpRule == nil && tcpRule == nil {
-		return nil, nil, fmt.Errorf("Can't find HTTPRule or TCPRule for Outer Service(%s)", port.ServiceID)
+	if len(httpRules) == 0 && len(tcpRules) == 0 {
+		return nil, nil, nil, fmt.Errorf("Can't find HTTPRule or TCPRule for Outer Service(%s)", port.ServiceID)
 	}
 
 	// create ingresses
 	var ingresses []*extensions.Ingress
-	var secret *corev1.Secret
-	// http
-	if httpRule != nil {
-		ing, sec, err := a.applyHTTPRule(httpRule, port, service)
+	var secrets []*corev1.Secret
+	var backendServices []*corev1.Service
+	// http, one ingress per rule
+	for _, httpRule := range httpRules {
+		ing, sec, backends, err := a.applyHTTPRule(httpRule, port, service)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		ingresses = append(ingresses, ing)
-		secret = sec
+		if sec != nil {
+			secrets = append(secrets, sec)
+		}
+		backendServices = append(backendServices, backends...)
 	}
 
-	// tcp
-	if tcpRule != nil {
-		ing, err := applyTCPRule(tcpRule, service, a.tenant.UUID)
+	// tcp, one ingress per rule
+	for _, tcpRule := range tcpRules {
+		ing, err := a.applyTCPRule(tcpRule, service)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		ingresses = append(ingresses, ing)
 	}
 
-	return ingresses, secret, nil
+	return ingresses, secrets, backendServices, nil
 }
 
 // applyTCPRule applies stream rule into ingress
 func (a *AppServiceBuild) applyHTTPRule(rule *model.HTTPRule, port *model.TenantServicesPort,
-	service *corev1.Service) (ing *extensions.Ingress, sec *corev1.Secret, err error) {
+	service *corev1.Service) (ing *extensions.Ingress, sec *corev1.Secret, backendServices []*corev1.Service, err error) {
 	// deal with empty path
 	path := strings.Replace(rule.Path, " ", "", -1)
 	if path == "" {
@@ -234,6 +276,13 @@ func (a *AppServiceBuild) applyHTTPRule(rule *model.HTTPRule, port *model.Tenant
 	if domain == "" {
 		domain = createDefaultDomain(a.tenant.Name, a.service.ServiceAlias, port.ContainerPort)
 	}
+
+	// one path per weighted backend, or port's own service if none are configured
+	paths, weight, backendServices, err := a.applyHTTPRuleBackends(rule, path, port, service)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	ing = &extensions.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("ing-%s-%s", domain, util.NewUUID()[0:8]),
@@ -245,15 +294,7 @@ func (a *AppServiceBuild) applyHTTPRule(rule *model.HTTPRule, port *model.Tenant
 					Host: rule.Domain,
 					IngressRuleValue: extensions.IngressRuleValue{
 						HTTP: &extensions.HTTPIngressRuleValue{
-							Paths: []extensions.HTTPIngressPath{
-								{
-									Path: path,
-									Backend: extensions.IngressBackend{
-										ServiceName: service.Name,
-										ServicePort: intstr.FromInt(port.ContainerPort),
-									},
-								},
-							},
+							Paths: paths,
 						},
 					},
 				},
@@ -263,6 +304,9 @@ func (a *AppServiceBuild) applyHTTPRule(rule *model.HTTPRule, port *model.Tenant
 
 	// parse annotations
 	annos := make(map[string]string)
+	if weight != "" {
+		annos[parser.GetAnnotationWithPrefix("weight")] = weight
+	}
 	// header
 	if rule.Header != "" {
 		annos[parser.GetAnnotationWithPrefix("header")] = rule.Header
@@ -273,56 +317,220 @@ func (a *AppServiceBuild) applyHTTPRule(rule *model.HTTPRule, port *model.Tenant
 	}
 	// certificate
 	if rule.CertificateID != "" {
-		cert, err := a.dbmanager.CertificateDao().GetCertificateByID(rule.CertificateID)
+		tlsSecret, isNew, err := a.getOrCreateTLSSecret(rule.CertificateID)
 		if err != nil {
-			return nil, nil, fmt.Errorf("Cant not get certificate by id(%s): %v", rule.CertificateID, err)
+			return nil, nil, nil, err
 		}
-		// create secret
-		sec = &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      fmt.Sprintf("certificate-%s", domain),
-				Namespace: a.tenant.UUID,
-			},
-			Data: map[string][]byte{
-				"tls.crt": []byte(cert.Certificate),
-				"tls.key": []byte(cert.PrivateKey),
-			},
-			Type: corev1.SecretTypeOpaque,
+		if isNew {
+			sec = tlsSecret
 		}
 		ing.Spec.TLS = []extensions.IngressTLS{
 			{
 				Hosts:      []string{domain},
-				SecretName: sec.Name,
+				SecretName: tlsSecret.Name,
 			},
 		}
 	}
 	// rule extension
 	ruleExtensions, err := a.dbmanager.RuleExtensionDao().GetRuleExtensionByRuleID(rule.UUID)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
+	rewriteTarget, authType, authSecretID, authRealm := "", "", "", ""
+	ingressClass := a.ingressClass
 	for _, extension := range ruleExtensions {
 		switch extension.Key {
 		case string(model.HTTPToHTTPS):
 			annos[parser.GetAnnotationWithPrefix("force-ssl-redirect")] = "true"
 		case string(model.LBType):
 			annos[parser.GetAnnotationWithPrefix("lb-type")] = extension.Value
+		case string(model.PathRewriteTarget):
+			rewriteTarget = extension.Value
+		case string(model.AuthType):
+			authType = extension.Value
+		case string(model.AuthSecret):
+			authSecretID = extension.Value
+		case string(model.AuthRealm):
+			authRealm = extension.Value
+		case string(model.WhitelistSourceRange):
+			annos[parser.GetAnnotationWithPrefix(parser.AnnotationWhitelistSourceRange)] = extension.Value
+		case string(model.IngressClass):
+			ingressClass = extension.Value
+		case string(model.BackendProtocol):
+			annos[parser.GetAnnotationWithPrefix(parser.AnnotationBackendProtocol)] = strings.ToUpper(extension.Value)
+		case string(model.CustomRequestHeaders):
+			annos[parser.GetAnnotationWithPrefix(parser.AnnotationCustomRequestHeaders)] = extension.Value
+		case string(model.CustomResponseHeaders):
+			annos[parser.GetAnnotationWithPrefix(parser.AnnotationCustomResponseHeaders)] = extension.Value
 		default:
 			logrus.Warnf("Unexpected RuleExtension Value: %s", extension.Value)
 		}
 	}
+	if ingressClass != "" {
+		annos["kubernetes.io/ingress.class"] = ingressClass
+	}
+	// basic-auth
+	if authType != "" {
+		authSecret, err := a.dbmanager.AuthSecretDao().GetAuthSecretByID(authSecretID)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("can not get auth secret by id(%s): %v", authSecretID, err)
+		}
+		if authSecret == nil {
+			return nil, nil, nil, fmt.Errorf("auth secret(%s) referenced by rule(%s) does not exist", authSecretID, rule.UUID)
+		}
+		annos[parser.GetAnnotationWithPrefix(parser.AnnotationAuthType)] = authType
+		annos[parser.GetAnnotationWithPrefix(parser.AnnotationAuthSecret)] = authSecret.SecretName
+		if authRealm != "" {
+			annos[parser.GetAnnotationWithPrefix(parser.AnnotationAuthRealm)] = authRealm
+		}
+	}
+	// path match type decides how the gateway matches and, if requested,
+	// rewrites the incoming path before it reaches the backend
+	switch rule.PathMatchType {
+	case model.ExactPath:
+		annos[parser.GetAnnotationWithPrefix("use-regex")] = "true"
+		for i := range paths {
+			paths[i].Path = fmt.Sprintf("^%s$", path)
+		}
+	case model.PathStrip:
+		annos[parser.GetAnnotationWithPrefix("rewrite-target")] = "/"
+	case model.PathPrefixStrip:
+		annos[parser.GetAnnotationWithPrefix("use-regex")] = "true"
+		annos[parser.GetAnnotationWithPrefix("rewrite-target")] = "/$2"
+		for i := range paths {
+			paths[i].Path = path + "(/|$)(.*)"
+		}
+	case model.AddPrefix:
+		if rewriteTarget != "" {
+			annos[parser.GetAnnotationWithPrefix("add-prefix")] = rewriteTarget
+		}
+	case model.ReplacePath:
+		if rewriteTarget != "" {
+			annos[parser.GetAnnotationWithPrefix("replace-path")] = rewriteTarget
+		}
+	case model.ReplacePathRegex:
+		if rewriteTarget != "" {
+			annos[parser.GetAnnotationWithPrefix("replace-path-regex")] = rewriteTarget
+		}
+	case model.PathPrefix, "":
+		// default prefix match, nothing extra to do
+	}
 	ing.SetAnnotations(annos)
 
-	return ing, sec, nil
+	return ing, sec, backendServices, nil
+}
+
+// applyHTTPRuleBackends converts the HTTPRuleBackends of rule into ingress
+// paths, one per backend, falling back to port's own service when none are
+// configured. The returned weight string is meant for the "weight" annotation.
+func (a *AppServiceBuild) applyHTTPRuleBackends(rule *model.HTTPRule, path string, port *model.TenantServicesPort,
+	service *corev1.Service) ([]extensions.HTTPIngressPath, string, []*corev1.Service, error) {
+	backends, err := a.dbmanager.HTTPRuleBackendDao().GetHTTPRuleBackendsByRuleID(rule.UUID)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("get http rule backends by rule id(%s): %v", rule.UUID, err)
+	}
+	if len(backends) == 0 {
+		return []extensions.HTTPIngressPath{
+			{
+				Path: path,
+				Backend: extensions.IngressBackend{
+					ServiceName: service.Name,
+					ServicePort: intstr.FromInt(port.ContainerPort),
+				},
+			},
+		}, "", nil, nil
+	}
+
+	var paths []extensions.HTTPIngressPath
+	var weights []string
+	var backendServices []*corev1.Service
+	for _, backend := range backends {
+		backendService, err := a.dbmanager.TenantServiceDao().GetServiceByID(backend.ServiceID)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("get service by id(%s): %v", backend.ServiceID, err)
+		}
+		if backendService == nil {
+			return nil, "", nil, fmt.Errorf("backend service(%s) referenced by rule(%s) does not exist", backend.ServiceID, rule.UUID)
+		}
+		backendPort, err := a.dbmanager.TenantServicesPortDao().GetPort(backend.ServiceID, backend.ContainerPort)
+		if err != nil {
+			return nil, "", nil, fmt.Errorf("get port(%d) of service(%s): %v", backend.ContainerPort, backend.ServiceID, err)
+		}
+		if backendPort == nil {
+			return nil, "", nil, fmt.Errorf("port(%d) of backend service(%s) does not exist", backend.ContainerPort, backend.ServiceID)
+		}
+
+		svc := a.createBackendInnerService(backendService, backendPort)
+		backendServices = append(backendServices, svc)
+		paths = append(paths, extensions.HTTPIngressPath{
+			Path: path,
+			Backend: extensions.IngressBackend{
+				ServiceName: svc.Name,
+				ServicePort: intstr.FromInt(int(svc.Spec.Ports[0].Port)),
+			},
+		})
+		weights = append(weights, fmt.Sprintf("%s:%d", svc.Name, backend.Weight))
+	}
+	return paths, strings.Join(weights, ","), backendServices, nil
+}
+
+// getOrCreateTLSSecret returns the corev1.Secret for a CertificateID, building
+// and validating it on first use. isNew tells the caller whether it's new.
+func (a *AppServiceBuild) getOrCreateTLSSecret(certificateID string) (sec *corev1.Secret, isNew bool, err error) {
+	if a.certSecrets == nil {
+		a.certSecrets = make(map[string]*corev1.Secret)
+	}
+	if sec, ok := a.certSecrets[certificateID]; ok {
+		return sec, false, nil
+	}
+
+	cert, err := a.dbmanager.CertificateDao().GetCertificateByID(certificateID)
+	if err != nil {
+		return nil, false, fmt.Errorf("Cant not get certificate by id(%s): %v", certificateID, err)
+	}
+	if err := validateCertificate(cert); err != nil {
+		return nil, false, fmt.Errorf("certificate(%s) is invalid: %v", certificateID, err)
+	}
+
+	sec = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("certificate-%s", certificateID),
+			Namespace: a.tenant.UUID,
+		},
+		Data: map[string][]byte{
+			"tls.crt": []byte(cert.Certificate),
+			"tls.key": []byte(cert.PrivateKey),
+		},
+		Type: corev1.SecretTypeTLS,
+	}
+	a.certSecrets[certificateID] = sec
+	return sec, true, nil
+}
+
+// validateCertificate checks that the certificate/key pair is well-formed,
+// matches, and is not expired
+func validateCertificate(cert *model.Certificate) error {
+	keyPair, err := tls.X509KeyPair([]byte(cert.Certificate), []byte(cert.PrivateKey))
+	if err != nil {
+		return fmt.Errorf("certificate and private key do not match: %v", err)
+	}
+	x509Cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("parse certificate: %v", err)
+	}
+	if time.Now().After(x509Cert.NotAfter) {
+		return fmt.Errorf("certificate expired at %s", x509Cert.NotAfter)
+	}
+	return nil
 }
 
 // applyTCPRule applies stream rule into ingress
-func applyTCPRule(rule *model.TCPRule, service *corev1.Service, namespace string) (ing *extensions.Ingress, err error) {
+func (a *AppServiceBuild) applyTCPRule(rule *model.TCPRule, service *corev1.Service) (ing *extensions.Ingress, err error) {
 	// create ingress
 	ing = &extensions.Ingress{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      fmt.Sprintf("ing-%s-%s", rule.IP, util.NewUUID()[0:8]),
-			Namespace: namespace,
+			Namespace: a.tenant.UUID,
 		},
 		Spec: extensions.IngressSpec{
 			Backend: &extensions.IngressBackend{
@@ -335,6 +543,9 @@ func applyTCPRule(rule *model.TCPRule, service *corev1.Service, namespace string
 	annos[parser.GetAnnotationWithPrefix("l4-enable")] = "true"
 	annos[parser.GetAnnotationWithPrefix("l4-host")] = rule.IP
 	annos[parser.GetAnnotationWithPrefix("l4-port")] = fmt.Sprintf("%v", rule.Port)
+	if a.ingressClass != "" {
+		annos["kubernetes.io/ingress.class"] = a.ingressClass
+	}
 	ing.SetAnnotations(annos)
 
 	return ing, nil
@@ -442,6 +653,40 @@ func (a *AppServiceBuild) createInnerService(port *model.TenantServicesPort) *co
 	return &service
 }
 
+//createBackendInnerService creates the inner k8s Service for an HTTPRuleBackend,
+//keyed off the backend's own TenantServices record instead of AppServiceBuild.service
+func (a *AppServiceBuild) createBackendInnerService(service *model.TenantServices, port *model.TenantServicesPort) *corev1.Service {
+	var svc corev1.Service
+	svc.Name = fmt.Sprintf("service-%d-%d", port.ID, port.ContainerPort)
+	svc.Labels = a.appService.GetCommonLabels(map[string]string{
+		"service_type":  "inner",
+		"name":          service.ServiceAlias + "Service",
+		"port_protocol": port.Protocol,
+		"creator":       "RainBond",
+		"service_id":    service.ServiceID,
+		"version":       service.DeployVersion,
+	})
+	if service.Replicas <= 1 {
+		svc.Labels["rainbond.com/tolerate-unready-endpoints"] = "true"
+	}
+	var servicePort corev1.ServicePort
+	if port.Protocol == "udp" {
+		servicePort.Protocol = "UDP"
+	} else {
+		servicePort.Protocol = "TCP"
+	}
+	servicePort.TargetPort = intstr.FromInt(port.ContainerPort)
+	servicePort.Port = int32(port.MappingPort)
+	if servicePort.Port == 0 {
+		servicePort.Port = int32(port.ContainerPort)
+	}
+	svc.Spec = corev1.ServiceSpec{
+		Ports:    []corev1.ServicePort{servicePort},
+		Selector: map[string]string{"name": service.ServiceAlias},
+	}
+	return &svc
+}
+
 func (a *AppServiceBuild) createOuterService(port *model.TenantServicesPort) *corev1.Service {
 	var service corev1.Service
 	service.Name = fmt.Sprintf("service-%d-%dout", port.ID, port.ContainerPort)
@@ -0,0 +1,115 @@
+// RAINBOND, Application Management Platform
+// Copyright (C) 2014-2017 Goodrain Co., Ltd.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version. For any non-GPL usage of Rainbond,
+// one or multiple Commercial Licenses authorized by Goodrain Co., Ltd.
+// must be obtained first.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package conversion
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/goodrain/rainbond/db/model"
+	extensions "k8s.io/api/extensions/v1beta1"
+)
+
+// genCert returns a self-signed cert/key PEM pair valid for [notBefore, notAfter]
+func genCert(t *testing.T, notBefore, notAfter time.Time) (certPEM, keyPEM string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	return certPEM, keyPEM
+}
+
+func TestValidateCertificateValid(t *testing.T) {
+	certPEM, keyPEM := genCert(t, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	cert := &model.Certificate{Certificate: certPEM, PrivateKey: keyPEM}
+	if err := validateCertificate(cert); err != nil {
+		t.Fatalf("expected valid certificate to pass, got: %v", err)
+	}
+}
+
+func TestValidateCertificateExpired(t *testing.T) {
+	certPEM, keyPEM := genCert(t, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+	cert := &model.Certificate{Certificate: certPEM, PrivateKey: keyPEM}
+	if err := validateCertificate(cert); err == nil {
+		t.Fatal("expected expired certificate to fail validation")
+	}
+}
+
+func TestValidateCertificateKeyMismatch(t *testing.T) {
+	certPEM, _ := genCert(t, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	_, otherKeyPEM := genCert(t, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	cert := &model.Certificate{Certificate: certPEM, PrivateKey: otherKeyPEM}
+	if err := validateCertificate(cert); err == nil {
+		t.Fatal("expected mismatched certificate/key to fail validation")
+	}
+}
+
+func TestAggregateIngressTLSHosts(t *testing.T) {
+	ingA := &extensions.Ingress{Spec: extensions.IngressSpec{TLS: []extensions.IngressTLS{
+		{SecretName: "certificate-1", Hosts: []string{"a.example.com"}},
+	}}}
+	ingB := &extensions.Ingress{Spec: extensions.IngressSpec{TLS: []extensions.IngressTLS{
+		{SecretName: "certificate-1", Hosts: []string{"b.example.com"}},
+	}}}
+	ingresses := []*extensions.Ingress{ingA, ingB}
+
+	aggregateIngressTLSHosts(ingresses)
+
+	for _, ing := range ingresses {
+		hosts := ing.Spec.TLS[0].Hosts
+		if len(hosts) != 2 {
+			t.Fatalf("expected both hosts aggregated onto every ingress sharing the secret, got %v", hosts)
+		}
+	}
+}
+
+func TestAggregateIngressTLSHostsDedups(t *testing.T) {
+	ingA := &extensions.Ingress{Spec: extensions.IngressSpec{TLS: []extensions.IngressTLS{
+		{SecretName: "certificate-1", Hosts: []string{"a.example.com"}},
+	}}}
+	ingB := &extensions.Ingress{Spec: extensions.IngressSpec{TLS: []extensions.IngressTLS{
+		{SecretName: "certificate-1", Hosts: []string{"a.example.com"}},
+	}}}
+	ingresses := []*extensions.Ingress{ingA, ingB}
+
+	aggregateIngressTLSHosts(ingresses)
+
+	if hosts := ingA.Spec.TLS[0].Hosts; len(hosts) != 1 {
+		t.Fatalf("expected duplicate host to be collapsed, got %v", hosts)
+	}
+}